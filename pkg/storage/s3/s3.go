@@ -1,18 +1,30 @@
 package s3
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net/url"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -20,11 +32,25 @@ type S3 struct {
 	url url.URL
 	// pathStyle option is not really used, but may be required
 	// at some point; see https://aws.amazon.com/blogs/aws/amazon-s3-path-deprecation-plan-the-rest-of-the-story/
-	pathStyle       bool
-	region          string
-	endpoint        string
-	accessKeyId     string
-	secretAccessKey string
+	pathStyle             bool
+	region                string
+	endpoint              string
+	accessKeyId           string
+	secretAccessKey       string
+	serverSideEncryption  types.ServerSideEncryption
+	kmsKeyId              string
+	storageClass          types.StorageClass
+	versioning            bool
+	partSize              int64
+	concurrency           int
+	leavePartsOnError     bool
+	profile               string
+	assumeRoleARN         string
+	assumeRoleSessionName string
+	assumeRoleExternalID  string
+	webIdentityTokenFile  string
+	webIdentityRoleARN    string
+	checksum              bool
 }
 
 type Option func(s *S3)
@@ -54,6 +80,70 @@ func WithSecretAccessKey(secretAccessKey string) Option {
 		s.secretAccessKey = secretAccessKey
 	}
 }
+// TODO: none of WithServerSideEncryption, WithKMSKeyID, or WithStorageClass
+// are reachable yet from outside this package - they still need flags/env
+// vars wired through the CLI and passed down to New.
+func WithServerSideEncryption(algo string) Option {
+	return func(s *S3) {
+		s.serverSideEncryption = types.ServerSideEncryption(algo)
+	}
+}
+func WithKMSKeyID(id string) Option {
+	return func(s *S3) {
+		s.kmsKeyId = id
+	}
+}
+func WithStorageClass(class string) Option {
+	return func(s *S3) {
+		s.storageClass = types.StorageClass(class)
+	}
+}
+func WithVersioning() Option {
+	return func(s *S3) {
+		s.versioning = true
+	}
+}
+func WithPartSize(partSize int64) Option {
+	return func(s *S3) {
+		s.partSize = partSize
+	}
+}
+func WithConcurrency(concurrency int) Option {
+	return func(s *S3) {
+		s.concurrency = concurrency
+	}
+}
+func WithLeavePartsOnError(leavePartsOnError bool) Option {
+	return func(s *S3) {
+		s.leavePartsOnError = leavePartsOnError
+	}
+}
+// TODO: WithProfile, WithAssumeRole, and WithWebIdentityToken are only
+// reachable by constructing an S3 backend directly - the CLI flags and env
+// parsing to expose this credentials chain to users still need to be added.
+func WithProfile(profile string) Option {
+	return func(s *S3) {
+		s.profile = profile
+	}
+}
+func WithAssumeRole(arn, sessionName, externalID string) Option {
+	return func(s *S3) {
+		s.assumeRoleARN = arn
+		s.assumeRoleSessionName = sessionName
+		s.assumeRoleExternalID = externalID
+	}
+}
+func WithWebIdentityToken(file, roleARN string) Option {
+	return func(s *S3) {
+		s.webIdentityTokenFile = file
+		s.webIdentityRoleARN = roleARN
+	}
+}
+func WithChecksum() Option {
+	return func(s *S3) {
+		s.checksum = true
+	}
+}
 
 func New(u url.URL, opts ...Option) *S3 {
 	s := &S3{url: u}
@@ -63,22 +153,64 @@ func New(u url.URL, opts ...Option) *S3 {
 	return s
 }
 
+// newUploader builds a manager.Uploader with this S3's tunables applied, so
+// Push/PushStream always upload through the same configured multipart
+// behaviour.
+func (s *S3) newUploader(client *s3.Client) *manager.Uploader {
+	return manager.NewUploader(client, func(u *manager.Uploader) {
+		if s.partSize > 0 {
+			u.PartSize = s.partSize
+		}
+		if s.concurrency > 0 {
+			u.Concurrency = s.concurrency
+		}
+		u.LeavePartsOnError = s.leavePartsOnError
+	})
+}
+
+// newClient builds the s3.Client every operation below uses, applying
+// pathStyle so it is actually honored instead of sitting unused on the
+// struct.
+func (s *S3) newClient(cfg aws.Config) *s3.Client {
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.UsePathStyle = s.pathStyle
+	})
+}
+
+// newDownloader builds a manager.Downloader with this S3's tunables applied.
+func (s *S3) newDownloader(client *s3.Client) *manager.Downloader {
+	return manager.NewDownloader(client, func(d *manager.Downloader) {
+		if s.partSize > 0 {
+			d.PartSize = s.partSize
+		}
+		if s.concurrency > 0 {
+			d.Concurrency = s.concurrency
+		}
+	})
+}
+
 func (s *S3) Pull(source, target string) (int64, error) {
+	return s.PullVersion(source, target, "")
+}
+
+// PullVersion downloads a specific version of an object from a versioned bucket.
+// An empty versionID behaves exactly like Pull, downloading the latest version.
+func (s *S3) PullVersion(source, target, versionID string) (int64, error) {
 	// TODO: need to find way to include cli opts and cli_s3_cp_opts
 	// old was:
 	// 		aws ${AWS_CLI_OPTS} s3 cp ${AWS_CLI_S3_CP_OPTS} "${DB_RESTORE_TARGET}" $TMPRESTORE
 
 	bucket, path := s.url.Hostname(), path.Join(s.url.Path, source)
 	// The session the S3 Downloader will use
-	cfg, err := getConfig(s.endpoint)
+	cfg, err := getConfig(s)
 	if err != nil {
 		return 0, fmt.Errorf("failed to load AWS config: %v", err)
 	}
 
-	client := s3.NewFromConfig(cfg)
+	client := s.newClient(cfg)
 
-	// Create a downloader with the session and default options
-	downloader := manager.NewDownloader(client)
+	// Create a downloader with the session and configured tunables
+	downloader := s.newDownloader(client)
 
 	// Create a file to write the S3 Object contents to.
 	f, err := os.Create(target)
@@ -87,52 +219,282 @@ func (s *S3) Pull(source, target string) (int64, error) {
 	}
 	defer f.Close()
 
-	// Write the contents of S3 Object to the file
-	n, err := downloader.Download(context.TODO(), f, &s3.GetObjectInput{
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(path),
-	})
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	// Write the contents of S3 Object to the file
+	n, err := downloader.Download(context.TODO(), f, input)
 	if err != nil {
 		return 0, fmt.Errorf("failed to download file, %v", err)
 	}
+
+	if s.checksum {
+		// Resolve which version was actually pulled so the checksum lookup
+		// matches it rather than falling back to an unqualified sidecar key
+		// that may belong to a different (e.g. newer) version. The bucket
+		// may have versioning enabled even when the caller didn't pass
+		// WithVersioning(), so this is driven by whether the object itself
+		// turns out to have a version id, not by the client-side flag.
+		checksumVersionID := versionID
+		if checksumVersionID == "" {
+			head, err := client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(path),
+			})
+			if err != nil {
+				return n, fmt.Errorf("failed to resolve pulled object version for checksum verification, %v", err)
+			}
+			checksumVersionID = aws.ToString(head.VersionId)
+		}
+		if err := verifyChecksum(context.TODO(), client, bucket, path, checksumVersionID, target); err != nil {
+			return n, err
+		}
+		if err := verifyETag(context.TODO(), client, bucket, path, checksumVersionID, target); err != nil {
+			return n, err
+		}
+	}
+
 	return n, nil
 }
 
 func (s *S3) Push(target, source string) (int64, error) {
+	// Create a file to read the contents to push into S3.
+	f, err := os.Open(source)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read input file %q, %v", source, err)
+	}
+	defer f.Close()
+
+	return s.PushStream(target, f)
+}
+
+// PushStream uploads directly from r, so a dump piped from mysqldump and a
+// compressor can be streamed straight to S3 without first landing on disk.
+func (s *S3) PushStream(target string, r io.Reader) (int64, error) {
 	// TODO: need to find way to include cli opts and cli_s3_cp_opts
 	// old was:
 	// 		aws ${AWS_CLI_OPTS} s3 cp ${AWS_CLI_S3_CP_OPTS} "${DB_RESTORE_TARGET}" $TMPRESTORE
 
 	bucket, key := s.url.Hostname(), s.url.Path
 	// The session the S3 Downloader will use
-	cfg, err := getConfig(s.endpoint)
+	cfg, err := getConfig(s)
 	if err != nil {
 		return 0, fmt.Errorf("failed to load AWS config: %v", err)
 	}
 
-	client := s3.NewFromConfig(cfg)
-	// Create an uploader with the session and default options
-	uploader := manager.NewUploader(client)
-
-	// Create a file to write the S3 Object contents to.
-	f, err := os.Open(source)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read input file %q, %v", source, err)
+	client := s.newClient(cfg)
+	// Create an uploader with the session and configured tunables
+	uploader := s.newUploader(client)
+
+	// With checksums enabled, hash the body as it is read so the sidecar
+	// holds the SHA256 of the whole object; for a multipart upload the
+	// uploader's own ChecksumSHA256 is a checksum of the parts' checksums.
+	h := sha256.New()
+	body := r
+	if s.checksum {
+		body = io.TeeReader(r, h)
 	}
-	defer f.Close()
 
-	// Write the contents of the file to the S3 object
-	_, err = uploader.Upload(context.TODO(), &s3.PutObjectInput{
+	// Write the contents of the reader to the S3 object
+	input := &s3.PutObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(path.Join(key, target)),
-		Body:   f,
-	})
+		Body:   body,
+	}
+	// A KMS key id implies SSE-KMS even if WithServerSideEncryption wasn't
+	// also given explicitly; otherwise the key would be silently ignored
+	// and the object would upload unencrypted.
+	sse := s.serverSideEncryption
+	if sse == "" && s.kmsKeyId != "" {
+		sse = types.ServerSideEncryptionAwsKms
+	}
+	if sse != "" {
+		input.ServerSideEncryption = sse
+		if sse == types.ServerSideEncryptionAwsKms && s.kmsKeyId != "" {
+			input.SSEKMSKeyId = aws.String(s.kmsKeyId)
+		}
+	}
+	if s.storageClass != "" {
+		input.StorageClass = s.storageClass
+	}
+	if s.checksum {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+	}
+	out, err := uploader.Upload(context.TODO(), input)
 	if err != nil {
 		return 0, fmt.Errorf("failed to upload file, %v", err)
 	}
+
+	if s.checksum && out.ChecksumSHA256 != nil {
+		// Record the digest under a version-qualified sidecar key so
+		// ReadDir/retention and a later PullVersion can verify the exact
+		// version pulled rather than whatever the latest digest happens to
+		// be. checksumSuffix is filtered back out of ReadDir, and Remove/
+		// RemoveVersion clean it up alongside the backup it belongs to.
+		objectKey := path.Join(key, target)
+		if _, err := client.PutObject(context.TODO(), &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(checksumKey(objectKey, aws.ToString(out.VersionID))),
+			Body:   bytes.NewReader([]byte(base64.StdEncoding.EncodeToString(h.Sum(nil)))),
+		}); err != nil {
+			return 0, fmt.Errorf("failed to store checksum, %v", err)
+		}
+	}
 	return 0, nil
 }
 
+// checksumSuffix marks the sidecar objects that store a SHA256 digest
+// alongside a backup. ReadDir filters keys containing it (including the
+// version-qualified sidecars from checksumKey) out of its results so they are never counted, sorted, or pruned as if they were
+// backups themselves.
+const checksumSuffix = ".sha256"
+
+// checksumKey returns the sidecar key a backup's digest is stored under.
+// When versionID is non-empty the sidecar is qualified by version, so each
+// version of a backup (once versioning is enabled) gets its own digest
+// instead of all versions sharing - and clobbering - a single one.
+func checksumKey(objectKey, versionID string) string {
+	if versionID != "" {
+		return objectKey + checksumSuffix + "." + versionID
+	}
+	return objectKey + checksumSuffix
+}
+
+// verifyChecksum compares the SHA256 of the file just downloaded to target
+// against the digest recorded at Push time for the exact version that was
+// pulled, failing loudly on a mismatch. A missing sidecar (e.g. a backup
+// pushed before checksums were enabled) is not an error, but any other
+// failure to read it (throttling, a permissions problem, a network blip)
+// is, since silently treating those the same as "not recorded" would defeat
+// the whole point of failing loudly on corruption.
+func verifyChecksum(ctx context.Context, client *s3.Client, bucket, path, versionID, target string) error {
+	result, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(checksumKey(path, versionID)),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			log.Debugf("no stored checksum found for %q, skipping verification: %v", path, err)
+			return nil
+		}
+		return fmt.Errorf("failed to fetch stored checksum for %q, %v", path, err)
+	}
+	defer result.Body.Close()
+
+	want, err := io.ReadAll(result.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read stored checksum, %v", err)
+	}
+
+	f, err := os.Open(target)
+	if err != nil {
+		return fmt.Errorf("failed to open downloaded file %q for checksum verification, %v", target, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("failed to compute checksum of %q, %v", target, err)
+	}
+	got := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	if got != string(want) {
+		return fmt.Errorf("checksum mismatch for %q: expected %s, got %s", target, want, got)
+	}
+	return nil
+}
+
+// verifyETag compares the MD5 of the file just downloaded to target against
+// the object's ETag, catching corruption even for backups pushed before
+// checksums were enabled (no sidecar digest to compare against). A
+// multipart upload's ETag is not a plain MD5 of the object body (it's a
+// hash of the parts' MD5s), so this only verifies single-part uploads,
+// i.e. "where feasible" as opposed to unconditionally.
+func verifyETag(ctx context.Context, client *s3.Client, bucket, path, versionID, target string) error {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(path),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	head, err := client.HeadObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to head object %q for ETag verification, %v", path, err)
+	}
+	etag := strings.Trim(aws.ToString(head.ETag), `"`)
+	if etag == "" || strings.Contains(etag, "-") {
+		// Empty or multipart ETag: nothing we can verify against a plain MD5.
+		return nil
+	}
+
+	f, err := os.Open(target)
+	if err != nil {
+		return fmt.Errorf("failed to open downloaded file %q for ETag verification, %v", target, err)
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("failed to compute MD5 of %q, %v", target, err)
+	}
+	got := hex.EncodeToString(h.Sum(nil))
+
+	if got != etag {
+		return fmt.Errorf("ETag mismatch for %q: expected %s, got %s", target, etag, got)
+	}
+	return nil
+}
+
+// PresignPush returns a time-limited URL that can be used to PUT the backup
+// directly into the bucket without handing out IAM credentials, e.g. for a
+// restricted-network cron job or a separate uploader process.
+//
+// TODO: there is no CLI subcommand exposing PresignPush/PresignPull yet -
+// they're only reachable by importing this package directly.
+func (s *S3) PresignPush(target string, ttl time.Duration) (string, error) {
+	cfg, err := getConfig(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to load AWS config: %v", err)
+	}
+
+	client := s3.NewPresignClient(s.newClient(cfg))
+	req, err := client.PresignPutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket: aws.String(s.url.Hostname()),
+		Key:    aws.String(path.Join(s.url.Path, target)),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign upload, %v", err)
+	}
+	return req.URL, nil
+}
+
+// PresignPull returns a time-limited URL that can be used to GET the backup
+// directly from the bucket, e.g. to hand an auditor a download link without
+// granting IAM access.
+func (s *S3) PresignPull(source string, ttl time.Duration) (string, error) {
+	cfg, err := getConfig(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to load AWS config: %v", err)
+	}
+
+	client := s3.NewPresignClient(s.newClient(cfg))
+	req, err := client.PresignGetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(s.url.Hostname()),
+		Key:    aws.String(path.Join(s.url.Path, source)),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign download, %v", err)
+	}
+	return req.URL, nil
+}
+
 func (s *S3) Protocol() string {
 	return "s3"
 }
@@ -143,13 +505,38 @@ func (s *S3) URL() string {
 
 func (s *S3) ReadDir(dirname string) ([]fs.FileInfo, error) {
 	// Get the AWS config
-	cfg, err := getConfig(s.endpoint)
+	cfg, err := getConfig(s)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %v", err)
 	}
 
 	// Create a new S3 service client
-	svc := s3.NewFromConfig(cfg)
+	svc := s.newClient(cfg)
+
+	if s.versioning {
+		// Call ListObjectVersions so that every historical version of an
+		// object is surfaced to the retention/listing layer, not just the
+		// current one.
+		result, err := svc.ListObjectVersions(context.TODO(), &s3.ListObjectVersionsInput{Bucket: aws.String(s.url.Hostname()), Prefix: aws.String(dirname)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list object versions, %v", err)
+		}
+
+		var files []fs.FileInfo
+		for _, item := range result.Versions {
+			if strings.Contains(*item.Key, checksumSuffix) {
+				continue
+			}
+			files = append(files, &s3FileInfo{
+				name:         *item.Key,
+				lastModified: *item.LastModified,
+				size:         item.Size,
+				versionId:    aws.ToString(item.VersionId),
+			})
+		}
+
+		return files, nil
+	}
 
 	// Call ListObjectsV2 with your bucket and prefix
 	result, err := svc.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{Bucket: aws.String(s.url.Hostname()), Prefix: aws.String(dirname)})
@@ -157,9 +544,13 @@ func (s *S3) ReadDir(dirname string) ([]fs.FileInfo, error) {
 		return nil, fmt.Errorf("failed to list objects, %v", err)
 	}
 
-	// Convert s3.Object to fs.FileInfo
+	// Convert s3.Object to fs.FileInfo, skipping the checksum sidecar objects
+	// PushStream writes alongside each backup.
 	var files []fs.FileInfo
 	for _, item := range result.Contents {
+		if strings.Contains(*item.Key, checksumSuffix) {
+			continue
+		}
 		files = append(files, &s3FileInfo{
 			name:         *item.Key,
 			lastModified: *item.LastModified,
@@ -171,24 +562,45 @@ func (s *S3) ReadDir(dirname string) ([]fs.FileInfo, error) {
 }
 
 func (s *S3) Remove(target string) error {
+	return s.RemoveVersion(target, "")
+}
+
+// RemoveVersion deletes a specific version of an object from a versioned
+// bucket, letting retention policies prune old versions as well as the
+// current one. An empty versionID behaves exactly like Remove.
+func (s *S3) RemoveVersion(target, versionID string) error {
 	// Get the AWS config
-	cfg, err := getConfig(s.endpoint)
+	cfg, err := getConfig(s)
 	if err != nil {
 		return fmt.Errorf("failed to load AWS config: %v", err)
 	}
 
 	// Create a new S3 service client
-	svc := s3.NewFromConfig(cfg)
+	svc := s.newClient(cfg)
 
-	// Call DeleteObject with your bucket and the key of the object you want to delete
-	_, err = svc.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+	input := &s3.DeleteObjectInput{
 		Bucket: aws.String(s.url.Hostname()),
 		Key:    aws.String(target),
-	})
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	// Call DeleteObject with your bucket and the key of the object you want to delete
+	_, err = svc.DeleteObject(context.TODO(), input)
 	if err != nil {
 		return fmt.Errorf("failed to delete object, %v", err)
 	}
 
+	// Best-effort: prune the checksum sidecar alongside the backup it
+	// belongs to, so pruning a backup doesn't orphan its digest forever.
+	if _, err := svc.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.url.Hostname()),
+		Key:    aws.String(checksumKey(target, versionID)),
+	}); err != nil {
+		log.Debugf("no checksum sidecar to remove for %q: %v", target, err)
+	}
+
 	return nil
 }
 
@@ -210,8 +622,16 @@ func getEndpoint(endpoint string) string {
 	return e
 }
 
-func getConfig(endpoint string) (aws.Config, error) {
-	cleanEndpoint := getEndpoint(endpoint)
+// getConfig builds the aws.Config used by every S3 operation. Credentials
+// are resolved through the standard SDK default chain (env, shared config,
+// EC2 IMDS, ECS task role, IRSA web identity, SSO) so that this also works
+// unmodified on EKS/EC2. WithProfile selects the shared config profile the
+// chain reads; WithAccessKeyId/WithSecretAccessKey, when both are set, take
+// the place of the chain's credentials. WithWebIdentityToken or, failing
+// that, WithAssumeRole then replaces the resolved credentials with STS-issued
+// ones; when both are set, only the web identity token is used.
+func getConfig(s *S3) (aws.Config, error) {
+	cleanEndpoint := getEndpoint(s.endpoint)
 	opts := []func(*config.LoadOptions) error{
 		config.WithEndpointResolverWithOptions(
 			aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
@@ -219,19 +639,53 @@ func getConfig(endpoint string) (aws.Config, error) {
 			}),
 		),
 	}
+	if s.region != "" {
+		opts = append(opts, config.WithRegion(s.region))
+	}
+	if s.profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(s.profile))
+	}
+	if s.accessKeyId != "" && s.secretAccessKey != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(s.accessKeyId, s.secretAccessKey, ""),
+		))
+	}
 	if log.IsLevelEnabled(log.TraceLevel) {
 		opts = append(opts, config.WithClientLogMode(aws.LogRequestWithBody|aws.LogResponse))
 	}
-	return config.LoadDefaultConfig(context.TODO(),
-		opts...,
-	)
 
+	cfg, err := config.LoadDefaultConfig(context.TODO(), opts...)
+	if err != nil {
+		return cfg, err
+	}
+
+	switch {
+	case s.webIdentityTokenFile != "":
+		stsClient := sts.NewFromConfig(cfg)
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(
+			stsClient, s.webIdentityRoleARN, stscreds.IdentityTokenFile(s.webIdentityTokenFile),
+		))
+	case s.assumeRoleARN != "":
+		stsClient := sts.NewFromConfig(cfg)
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, s.assumeRoleARN,
+			func(o *stscreds.AssumeRoleOptions) {
+				if s.assumeRoleSessionName != "" {
+					o.RoleSessionName = s.assumeRoleSessionName
+				}
+				if s.assumeRoleExternalID != "" {
+					o.ExternalID = aws.String(s.assumeRoleExternalID)
+				}
+			}))
+	}
+
+	return cfg, nil
 }
 
 type s3FileInfo struct {
 	name         string
 	lastModified time.Time
 	size         int64
+	versionId    string
 }
 
 func (s s3FileInfo) Name() string       { return s.name }
@@ -240,3 +694,7 @@ func (s s3FileInfo) Mode() os.FileMode  { return 0 } // Not applicable in S3
 func (s s3FileInfo) ModTime() time.Time { return s.lastModified }
 func (s s3FileInfo) IsDir() bool        { return false } // Not applicable in S3
 func (s s3FileInfo) Sys() interface{}   { return nil }   // Not applicable in S3
+
+// VersionId returns the S3 object version this FileInfo was read from, or
+// an empty string when the backend is not running in versioned mode.
+func (s s3FileInfo) VersionId() string { return s.versionId }
@@ -0,0 +1,319 @@
+//go:build integration
+
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/testcontainers/testcontainers-go/modules/localstack"
+)
+
+// testBucket bundles the backend under test with the raw *s3.Client used to
+// set up fixtures and assert on the bucket's actual contents (e.g. to catch
+// sidecar objects the backend itself is supposed to hide).
+type testBucket struct {
+	backend *S3
+	client  *s3.Client
+	bucket  string
+}
+
+// newTestBucket starts a LocalStack container, creates a bucket in it, and
+// returns a backend constructed against the container's endpoint along with
+// a cleanup func. Tests that need path-style vs. virtual-hosted access pass
+// that through pathStyle; extra lets a test layer on options such as
+// WithChecksum or WithVersioning.
+func newTestBucket(t *testing.T, pathStyle bool, extra ...Option) (*testBucket, func()) {
+	t.Helper()
+	ctx := context.Background()
+
+	container, err := localstack.Run(ctx, "localstack/localstack:3.0")
+	if err != nil {
+		t.Fatalf("failed to start localstack: %v", err)
+	}
+	cleanup := func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate localstack: %v", err)
+		}
+	}
+
+	endpoint, err := container.PortEndpoint(ctx, "4566/tcp", "http")
+	if err != nil {
+		cleanup()
+		t.Fatalf("failed to get localstack endpoint: %v", err)
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRegion("us-east-1"),
+		awsconfig.WithCredentialsProvider(aws.AnonymousCredentials{}),
+		awsconfig.WithEndpointResolverWithOptions(
+			aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+				return aws.Endpoint{URL: endpoint}, nil
+			}),
+		),
+	)
+	if err != nil {
+		cleanup()
+		t.Fatalf("failed to load AWS config: %v", err)
+	}
+
+	bucket := "mysql-backup-integration"
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) { o.UsePathStyle = pathStyle })
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		cleanup()
+		t.Fatalf("failed to create bucket: %v", err)
+	}
+
+	opts := append([]Option{WithEndpoint(endpoint), WithRegion("us-east-1"), WithAccessKeyId("test"), WithSecretAccessKey("test")}, extra...)
+	if pathStyle {
+		opts = append(opts, WithPathStyle())
+	}
+	backend := New(url.URL{Scheme: "s3", Host: bucket}, opts...)
+
+	return &testBucket{backend: backend, client: client, bucket: bucket}, cleanup
+}
+
+func TestIntegrationPushPullReadDirRemove(t *testing.T) {
+	for _, pathStyle := range []bool{false, true} {
+		name := "virtual-hosted"
+		if pathStyle {
+			name = "path-style"
+		}
+		t.Run(name, func(t *testing.T) {
+			tb, cleanup := newTestBucket(t, pathStyle)
+			defer cleanup()
+			backend := tb.backend
+
+			dir := t.TempDir()
+			source := filepath.Join(dir, "backup.sql.gz")
+			want := []byte("not a real mysqldump, just integration test fixture data")
+			if err := os.WriteFile(source, want, 0o644); err != nil {
+				t.Fatalf("failed to write fixture file: %v", err)
+			}
+
+			if _, err := backend.Push("backup.sql.gz", source); err != nil {
+				t.Fatalf("Push failed: %v", err)
+			}
+
+			entries, err := backend.ReadDir("")
+			if err != nil {
+				t.Fatalf("ReadDir failed: %v", err)
+			}
+			if len(entries) != 1 || entries[0].Name() != "backup.sql.gz" {
+				t.Fatalf("ReadDir returned unexpected entries: %v", entries)
+			}
+
+			target := filepath.Join(dir, "restored.sql.gz")
+			if _, err := backend.Pull("backup.sql.gz", target); err != nil {
+				t.Fatalf("Pull failed: %v", err)
+			}
+			got, err := os.ReadFile(target)
+			if err != nil {
+				t.Fatalf("failed to read restored file: %v", err)
+			}
+			if string(got) != string(want) {
+				t.Fatalf("restored content mismatch: got %q, want %q", got, want)
+			}
+
+			if err := backend.Remove("backup.sql.gz"); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+			entries, err = backend.ReadDir("")
+			if err != nil {
+				t.Fatalf("ReadDir after Remove failed: %v", err)
+			}
+			if len(entries) != 0 {
+				t.Fatalf("expected no entries after Remove, got %v", entries)
+			}
+		})
+	}
+}
+
+func TestIntegrationCustomEndpointRewrite(t *testing.T) {
+	// getEndpoint rewrites 127.0.0.1 to localhost; make sure a backend
+	// pointed at a loopback-literal endpoint still reaches LocalStack.
+	tb, cleanup := newTestBucket(t, true)
+	defer cleanup()
+	backend := tb.backend
+
+	u, err := url.Parse(backend.endpoint)
+	if err != nil {
+		t.Fatalf("failed to parse endpoint: %v", err)
+	}
+	if u.Hostname() != "127.0.0.1" && u.Hostname() != "localhost" {
+		t.Skipf("localstack endpoint %q is not loopback, nothing to rewrite", backend.endpoint)
+	}
+	// Pin the endpoint to the loopback literal so the push below goes
+	// through getEndpoint's rewrite even when LocalStack reports localhost.
+	u.Host = "127.0.0.1:" + u.Port()
+	backend.endpoint = u.String()
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "backup.sql.gz")
+	if err := os.WriteFile(source, []byte("fixture"), 0o644); err != nil {
+		t.Fatalf("failed to write fixture file: %v", err)
+	}
+
+	if _, err := backend.Push(fmt.Sprintf("endpoint-rewrite-%s.sql.gz", t.Name()), source); err != nil {
+		t.Fatalf("Push over rewritten endpoint failed: %v", err)
+	}
+}
+
+// TestIntegrationChecksumRoundTrip exercises WithChecksum end to end: a
+// healthy Pull must succeed, ReadDir must not surface the .sha256 sidecar as
+// a phantom backup, Remove must prune the sidecar alongside the backup, and
+// a corrupted object must fail Pull loudly instead of silently succeeding.
+func TestIntegrationChecksumRoundTrip(t *testing.T) {
+	tb, cleanup := newTestBucket(t, false, WithChecksum())
+	defer cleanup()
+	backend := tb.backend
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "backup.sql.gz")
+	want := []byte("checksum me please")
+	if err := os.WriteFile(source, want, 0o644); err != nil {
+		t.Fatalf("failed to write fixture file: %v", err)
+	}
+
+	if _, err := backend.Push("backup.sql.gz", source); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	entries, err := backend.ReadDir("")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "backup.sql.gz" {
+		t.Fatalf("ReadDir should surface exactly the backup, not its checksum sidecar: got %v", entries)
+	}
+
+	target := filepath.Join(dir, "restored.sql.gz")
+	if _, err := backend.Pull("backup.sql.gz", target); err != nil {
+		t.Fatalf("Pull of an intact backup should succeed: %v", err)
+	}
+
+	// Corrupt the object in place, bypassing the backend, and confirm Pull
+	// fails loudly instead of restoring silently-corrupted data.
+	if _, err := tb.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(tb.bucket),
+		Key:    aws.String("backup.sql.gz"),
+		Body:   strings.NewReader("corrupted data, not what was pushed"),
+	}); err != nil {
+		t.Fatalf("failed to corrupt fixture object: %v", err)
+	}
+	if _, err := backend.Pull("backup.sql.gz", target); err == nil {
+		t.Fatal("Pull of a corrupted backup should fail checksum verification")
+	}
+
+	if err := backend.Remove("backup.sql.gz"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	raw, err := tb.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(tb.bucket)})
+	if err != nil {
+		t.Fatalf("failed to list raw bucket contents: %v", err)
+	}
+	if len(raw.Contents) != 0 {
+		t.Fatalf("Remove should also prune the checksum sidecar, bucket still has: %v", raw.Contents)
+	}
+}
+
+// TestIntegrationVersionedPullPrune exercises WithVersioning end to end:
+// ReadDir must surface every version, PullVersion must restore the exact
+// version requested rather than always the latest, and RemoveVersion must
+// prune only the targeted version.
+func TestIntegrationVersionedPullPrune(t *testing.T) {
+	tb, cleanup := newTestBucket(t, false, WithVersioning())
+	defer cleanup()
+	backend := tb.backend
+	ctx := context.Background()
+
+	if _, err := tb.client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(tb.bucket),
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	}); err != nil {
+		t.Fatalf("failed to enable bucket versioning: %v", err)
+	}
+
+	dir := t.TempDir()
+	v1 := filepath.Join(dir, "v1.sql.gz")
+	v2 := filepath.Join(dir, "v2.sql.gz")
+	if err := os.WriteFile(v1, []byte("version one"), 0o644); err != nil {
+		t.Fatalf("failed to write v1 fixture: %v", err)
+	}
+	if err := os.WriteFile(v2, []byte("version two"), 0o644); err != nil {
+		t.Fatalf("failed to write v2 fixture: %v", err)
+	}
+
+	if _, err := backend.Push("backup.sql.gz", v1); err != nil {
+		t.Fatalf("Push v1 failed: %v", err)
+	}
+	if _, err := backend.Push("backup.sql.gz", v2); err != nil {
+		t.Fatalf("Push v2 failed: %v", err)
+	}
+
+	entries, err := backend.ReadDir("")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected both versions surfaced, got %v", entries)
+	}
+
+	type versioned interface{ VersionId() string }
+	var oldVersionID, newVersionID string
+	for _, e := range entries {
+		vi, ok := e.(versioned)
+		if !ok {
+			t.Fatalf("entry %v does not expose a version id", e)
+		}
+		head, err := tb.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:    aws.String(tb.bucket),
+			Key:       aws.String("backup.sql.gz"),
+			VersionId: aws.String(vi.VersionId()),
+		})
+		if err != nil {
+			t.Fatalf("failed to head version %q: %v", vi.VersionId(), err)
+		}
+		if head.ContentLength == int64(len("version one")) {
+			oldVersionID = vi.VersionId()
+		} else {
+			newVersionID = vi.VersionId()
+		}
+	}
+	if oldVersionID == "" || newVersionID == "" {
+		t.Fatalf("could not distinguish the two pushed versions among %v", entries)
+	}
+
+	restored := filepath.Join(dir, "restored.sql.gz")
+	if _, err := backend.PullVersion("backup.sql.gz", restored, oldVersionID); err != nil {
+		t.Fatalf("PullVersion of the older version failed: %v", err)
+	}
+	got, err := os.ReadFile(restored)
+	if err != nil {
+		t.Fatalf("failed to read restored file: %v", err)
+	}
+	if string(got) != "version one" {
+		t.Fatalf("PullVersion restored the wrong version: got %q, want %q", got, "version one")
+	}
+
+	if err := backend.RemoveVersion("backup.sql.gz", oldVersionID); err != nil {
+		t.Fatalf("RemoveVersion failed: %v", err)
+	}
+	entries, err = backend.ReadDir("")
+	if err != nil {
+		t.Fatalf("ReadDir after RemoveVersion failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the newer version to remain, got %v", entries)
+	}
+}